@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// actionsFor builds the ntfy Actions header for a torrent on inst, or
+// returns "" if NTFY_ACTIONS isn't enabled. ntfy caps notifications at 3
+// action buttons, so the default set is Pause/Resume/Delete - priority
+// changes are still reachable via /action/priority directly, just not
+// surfaced as a button.
+func actionsFor(inst *Instance, hash string) string {
+	if !ntfyActionsEnabled {
+		return ""
+	}
+	pause := actionURL(inst, "pause", hash, nil)
+	resume := actionURL(inst, "resume", hash, nil)
+	del := actionURL(inst, "delete", hash, url.Values{"files": {"true"}})
+	return fmt.Sprintf(
+		"http, Pause, %s, method=POST; http, Resume, %s, method=POST; http, Delete, %s, method=POST, clear=true",
+		pause, resume, del,
+	)
+}
+
+// actionURL builds a callback URL on the sidecar's own HTTP server for an
+// ntfy "http" action, carrying the instance ID and the shared secret so
+// random ntfy subscribers can't drive the qBit instance.
+func actionURL(inst *Instance, action, hash string, extra url.Values) string {
+	v := url.Values{}
+	v.Set("instance", inst.ID)
+	v.Set("hash", hash)
+	v.Set("token", actionToken)
+	for k, vals := range extra {
+		for _, val := range vals {
+			v.Add(k, val)
+		}
+	}
+	return fmt.Sprintf("%s/action/%s?%s", sidecarURL, action, v.Encode())
+}
+
+func handleActionPause(w http.ResponseWriter, r *http.Request) {
+	inst, hash, ok := validateAction(w, r)
+	if !ok {
+		return
+	}
+	runAction(w, inst, hash, "/api/v2/torrents/pause", nil)
+}
+
+func handleActionResume(w http.ResponseWriter, r *http.Request) {
+	inst, hash, ok := validateAction(w, r)
+	if !ok {
+		return
+	}
+	runAction(w, inst, hash, "/api/v2/torrents/resume", nil)
+}
+
+func handleActionDelete(w http.ResponseWriter, r *http.Request) {
+	inst, hash, ok := validateAction(w, r)
+	if !ok {
+		return
+	}
+	deleteFiles := r.URL.Query().Get("files") == "true"
+	runAction(w, inst, hash, "/api/v2/torrents/delete", url.Values{"deleteFiles": {strconv.FormatBool(deleteFiles)}})
+}
+
+func handleActionPriority(w http.ResponseWriter, r *http.Request) {
+	inst, hash, ok := validateAction(w, r)
+	if !ok {
+		return
+	}
+	endpoint := "/api/v2/torrents/topPrio"
+	if r.URL.Query().Get("level") == "bottom" {
+		endpoint = "/api/v2/torrents/bottomPrio"
+	}
+	runAction(w, inst, hash, endpoint, nil)
+}
+
+// validateAction checks the shared-secret token, resolves the target
+// instance, and extracts the hash query param - writing an error response
+// and returning ok=false if any of those are missing or wrong.
+func validateAction(w http.ResponseWriter, r *http.Request) (inst *Instance, hash string, ok bool) {
+	if token := r.URL.Query().Get("token"); token == "" || token != actionToken {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return nil, "", false
+	}
+
+	inst, instOK := instanceLookup(r.URL.Query().Get("instance"))
+	if !instOK {
+		http.Error(w, "Unknown or missing 'instance' query parameter", 400)
+		return nil, "", false
+	}
+
+	hash = r.URL.Query().Get("hash")
+	if hash == "" {
+		http.Error(w, "Missing 'hash' query parameter", 400)
+		return nil, "", false
+	}
+	return inst, hash, true
+}
+
+// runAction posts hash (plus any extra form values) to a qBit Web API
+// endpoint on inst, reusing the shared authenticated client from the sync
+// poller rather than logging in per request.
+func runAction(w http.ResponseWriter, inst *Instance, hash, endpoint string, extra url.Values) {
+	if err := qbitPostAction(inst, endpoint, hash, extra); err != nil {
+		log.Printf("[%s] [%s] Action %s failed: %v", inst.ID, hash, endpoint, err)
+		http.Error(w, "qBit action failed", http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(200)
+}
+
+func qbitPostAction(inst *Instance, endpoint, hash string, extra url.Values) error {
+	client := inst.getClient()
+	if client == nil {
+		return fmt.Errorf("qBit session not ready yet")
+	}
+
+	data := url.Values{"hashes": {hash}}
+	for k, v := range extra {
+		data[k] = v
+	}
+
+	resp, err := client.PostForm(inst.Host+endpoint, data)
+	if err != nil {
+		recordQbitAPIError(inst.ID)
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != 200 {
+		recordQbitAPIError(inst.ID)
+		return fmt.Errorf("qBit API returned status: %d", resp.StatusCode)
+	}
+	return nil
+}
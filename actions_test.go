@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestActionsFor(t *testing.T) {
+	oldEnabled, oldURL, oldToken := ntfyActionsEnabled, sidecarURL, actionToken
+	t.Cleanup(func() {
+		ntfyActionsEnabled, sidecarURL, actionToken = oldEnabled, oldURL, oldToken
+	})
+
+	inst := newInstance("home", "http://qbit.local", "", "", "topic", nil)
+
+	ntfyActionsEnabled = false
+	if got := actionsFor(inst, "abc"); got != "" {
+		t.Errorf("expected empty Actions header when disabled, got %q", got)
+	}
+
+	ntfyActionsEnabled = true
+	sidecarURL = "http://sidecar.local:9090"
+	actionToken = "secret"
+
+	got := actionsFor(inst, "abc")
+	for _, want := range []string{"Pause", "Resume", "Delete", "token=secret", "hash=abc", "instance=home"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected Actions header to contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestValidateAction(t *testing.T) {
+	oldToken, oldByID, oldInstances := actionToken, instanceByID, instances
+	inst := newInstance("home", "http://qbit.local", "", "", "topic", nil)
+	actionToken = "secret"
+	instanceByID = map[string]*Instance{"home": inst}
+	instances = []*Instance{inst}
+	t.Cleanup(func() {
+		actionToken, instanceByID, instances = oldToken, oldByID, oldInstances
+	})
+
+	tests := []struct {
+		name       string
+		target     string
+		expectOK   bool
+		expectCode int
+	}{
+		{"Valid", "/action/pause?token=secret&instance=home&hash=abc", true, 0},
+		{"Missing token", "/action/pause?instance=home&hash=abc", false, 403},
+		{"Wrong token", "/action/pause?token=wrong&instance=home&hash=abc", false, 403},
+		{"Unknown instance", "/action/pause?token=secret&instance=seedbox&hash=abc", false, 400},
+		{"Missing hash", "/action/pause?token=secret&instance=home", false, 400},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.target, nil)
+			w := httptest.NewRecorder()
+
+			gotInst, hash, ok := validateAction(w, req)
+			if ok != tt.expectOK {
+				t.Fatalf("expected ok=%v, got %v", tt.expectOK, ok)
+			}
+			if tt.expectOK {
+				if hash != "abc" {
+					t.Errorf("expected hash 'abc', got %q", hash)
+				}
+				if gotInst != inst {
+					t.Errorf("expected resolved instance %v, got %v", inst, gotInst)
+				}
+			}
+			if !tt.expectOK && w.Code != tt.expectCode {
+				t.Errorf("expected status %d, got %d", tt.expectCode, w.Code)
+			}
+		})
+	}
+}
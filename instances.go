@@ -0,0 +1,145 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const instanceEnvPrefix = "QBIT_INSTANCE_"
+
+// Instance is one qBittorrent target the sidecar talks to. Each instance
+// owns its own session, torrent cache and set of monitored hashes, so a
+// single sidecar process can front a home box and a seedbox at once.
+type Instance struct {
+	ID             string
+	Host           string
+	User           string
+	Pass           string
+	Topic          string
+	CategoryFilter []string
+
+	mu             sync.Mutex
+	client         *http.Client
+	torrentCache   map[string]*Torrent
+	activeMonitors map[string]*monitorState
+}
+
+var (
+	instances    []*Instance
+	instanceByID map[string]*Instance
+)
+
+// instanceLookup resolves the ?instance= query parameter. An empty id is
+// only valid when exactly one instance is configured.
+func instanceLookup(id string) (*Instance, bool) {
+	if id == "" {
+		if len(instances) == 1 {
+			return instances[0], true
+		}
+		return nil, false
+	}
+	inst, ok := instanceByID[id]
+	return inst, ok
+}
+
+// setClient publishes the *http.Client startupScan established once the
+// initial sync completes, guarded by mu since action handlers read it from
+// a different goroutine.
+func (inst *Instance) setClient(client *http.Client) {
+	inst.mu.Lock()
+	inst.client = client
+	inst.mu.Unlock()
+}
+
+// getClient returns the instance's qBit session client, or nil if
+// startupScan hasn't finished yet.
+func (inst *Instance) getClient() *http.Client {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	return inst.client
+}
+
+// trackedCategory reports whether a torrent's category passes this
+// instance's optional CATEGORY_FILTER allowlist. startupScan only resumes
+// monitors for torrents that pass; the torrent cache still holds every
+// category.
+func (inst *Instance) trackedCategory(category string) bool {
+	if len(inst.CategoryFilter) == 0 {
+		return true
+	}
+	for _, c := range inst.CategoryFilter {
+		if strings.EqualFold(c, category) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseInstances builds the instance list from QBIT_INSTANCE_<NAME>_*
+// env vars, falling back to the legacy single-instance QBIT_HOST/USER/PASS
+// vars (instance ID "default") when none are set.
+func parseInstances() []*Instance {
+	names := map[string]bool{}
+	for _, kv := range os.Environ() {
+		key := strings.SplitN(kv, "=", 2)[0]
+		if !strings.HasPrefix(key, instanceEnvPrefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, instanceEnvPrefix)
+		for _, suffix := range []string{"_HOST", "_USER", "_PASS", "_TOPIC", "_CATEGORY_FILTER"} {
+			if name := strings.TrimSuffix(rest, suffix); name != rest {
+				names[name] = true
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return []*Instance{newInstance(
+			"default",
+			getEnv("QBIT_HOST", "http://localhost:8080"),
+			getEnv("QBIT_USER", ""),
+			getEnv("QBIT_PASS", ""),
+			ntfyTopic,
+			nil,
+		)}
+	}
+
+	ordered := make([]string, 0, len(names))
+	for name := range names {
+		ordered = append(ordered, name)
+	}
+	sort.Strings(ordered)
+
+	result := make([]*Instance, 0, len(ordered))
+	for _, name := range ordered {
+		var categoryFilter []string
+		if raw := getEnv(instanceEnvPrefix+name+"_CATEGORY_FILTER", ""); raw != "" {
+			categoryFilter = strings.Split(raw, ",")
+		}
+		result = append(result, newInstance(
+			strings.ToLower(name),
+			mustGetEnv(instanceEnvPrefix+name+"_HOST"),
+			getEnv(instanceEnvPrefix+name+"_USER", ""),
+			getEnv(instanceEnvPrefix+name+"_PASS", ""),
+			getEnv(instanceEnvPrefix+name+"_TOPIC", ntfyTopic),
+			categoryFilter,
+		))
+	}
+	return result
+}
+
+func newInstance(id, host, user, pass, topic string, categoryFilter []string) *Instance {
+	return &Instance{
+		ID:             id,
+		Host:           host,
+		User:           user,
+		Pass:           pass,
+		Topic:          topic,
+		CategoryFilter: categoryFilter,
+		torrentCache:   make(map[string]*Torrent),
+		activeMonitors: make(map[string]*monitorState),
+	}
+}
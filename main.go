@@ -2,13 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"math"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"os"
 	"os/signal"
@@ -21,9 +19,6 @@ import (
 
 // --- Configuration ---
 var (
-	qbitHost       string
-	qbitUser       string
-	qbitPass       string
 	ntfyServer     string
 	ntfyUser       string
 	ntfyPass       string
@@ -32,52 +27,81 @@ var (
 	ntfyPrioComp   string
 	notifyComplete bool
 	progressFormat string
-	pollInt        = 5 * time.Second
+	pollInt        = 1 * time.Second
+
+	ntfyActionsEnabled bool
+	sidecarURL         string
+	actionToken        string
+
+	speedWindow int
 )
 
 // --- State ---
 var (
-	activeMonitors = make(map[string]bool)
-	mutex          sync.Mutex
-	appCtx         context.Context
-	appCancel      context.CancelFunc
-	appWg          sync.WaitGroup
+	appCtx    context.Context
+	appCancel context.CancelFunc
+	appWg     sync.WaitGroup
 )
 
 // Torrent struct for JSON parsing
 type Torrent struct {
-	Hash     string  `json:"hash"`
-	Name     string  `json:"name"`
-	Progress float64 `json:"progress"`
-	Eta      int     `json:"eta"`
-	DlSpeed  int     `json:"dlspeed"`
-	State    string  `json:"state"`
+	Hash       string  `json:"hash"`
+	Name       string  `json:"name"`
+	Progress   float64 `json:"progress"`
+	Eta        int     `json:"eta"`
+	DlSpeed    int     `json:"dlspeed"`
+	State      string  `json:"state"`
+	Category   string  `json:"category"`
+	Tags       string  `json:"tags"`
+	Downloaded int64   `json:"downloaded"`
+	Size       int64   `json:"size"`
 }
 
 func main() {
 	log.SetFlags(0) // K8s handles timestamps
 
 	// 1. Config Check
-	qbitHost = getEnv("QBIT_HOST", "http://localhost:8080")
-	qbitUser = getEnv("QBIT_USER", "")
-	qbitPass = getEnv("QBIT_PASS", "")
-
 	ntfyServer = strings.TrimRight(getEnv("NTFY_SERVER", "https://ntfy.sh"), "/")
 	ntfyUser = getEnv("NTFY_USER", "")
 	ntfyPass = getEnv("NTFY_PASS", "")
-	ntfyTopic = mustGetEnv("NTFY_TOPIC")
+	ntfyTopic = mustGetEnv("NTFY_TOPIC")                 // default topic; instances may override via QBIT_INSTANCE_<name>_TOPIC
 	ntfyPrioProg = getEnv("NTFY_PRIORITY_PROGRESS", "2") // Default: Low (no sound/vibe)
 	ntfyPrioComp = getEnv("NTFY_PRIORITY_COMPLETE", "3") // Default: Default (sound/vibe)
 
 	notifyComplete = getEnvBool("NOTIFY_COMPLETE", true)
 	progressFormat = getEnv("PROGRESS_FORMAT", "bar") // "bar" or "percent"
 
+	ntfyActionsEnabled = getEnvBool("NTFY_ACTIONS", false)
+
+	speedWindow = getEnvInt("SPEED_WINDOW", 12) // samples kept per torrent for rate smoothing
+
+	instances = parseInstances()
+	instanceByID = make(map[string]*Instance, len(instances))
+	for _, inst := range instances {
+		instanceByID[inst.ID] = inst
+	}
+
+	parseRouting()
+
 	// 2. Start Trigger Server
 	http.HandleFunc("/track", handleTrackRequest)
+	http.HandleFunc("/metrics", handleMetrics)
+
+	if ntfyActionsEnabled {
+		sidecarURL = strings.TrimRight(mustGetEnv("SIDECAR_URL"), "/")
+		actionToken = mustGetEnv("ACTION_TOKEN")
+
+		http.HandleFunc("/action/pause", handleActionPause)
+		http.HandleFunc("/action/resume", handleActionResume)
+		http.HandleFunc("/action/delete", handleActionDelete)
+		http.HandleFunc("/action/priority", handleActionPriority)
+	}
 
 	port := "9090"
 	log.Printf("Sidecar listening on :%s", port)
-	log.Printf("Config: Host=%s Auth=%v Topic=%s/%s NtfyAuth=%v", qbitHost, qbitUser != "", ntfyServer, ntfyTopic, ntfyUser != "")
+	for _, inst := range instances {
+		log.Printf("Config: Instance=%s Host=%s Auth=%v Topic=%s/%s NtfyAuth=%v", inst.ID, inst.Host, inst.User != "", ntfyServer, inst.Topic, ntfyUser != "")
+	}
 
 	// Global Context for shutdown signaling
 	appCtx, appCancel = context.WithCancel(context.Background())
@@ -85,9 +109,13 @@ func main() {
 
 	// ...
 
-	// 3. Run Startup Scan (Background)
-	appWg.Add(1)
-	go startupScan()
+	// 3. Run one sync-poller per instance (background). Each performs the
+	// initial full sync against qBit's maindata endpoint and then keeps
+	// polling incrementally.
+	for _, inst := range instances {
+		appWg.Add(1)
+		go startupScan(inst)
+	}
 
 	server := &http.Server{
 		Addr:         ":" + port,
@@ -129,221 +157,68 @@ func main() {
 	log.Println("Sidecar exited gracefully")
 }
 
-func startupScan() {
-	defer appWg.Done()
-
-	// Retry loop to wait for qBittorrent to be ready
-	jar, _ := cookiejar.New(nil)
-	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
-
-	for {
-		// Check for shutdown
-		select {
-		case <-appCtx.Done():
-			return
-		default:
-		}
-
-		log.Println("Startup: Attempting to connect to qBittorrent...")
-
-		// Helper for interruptible sleep
-		sleepOrExit := func(d time.Duration) bool {
-			select {
-			case <-time.After(d):
-				return false
-			case <-appCtx.Done():
-				return true
-			}
-		}
-
-		// 1. Auth (if required)
-		if qbitUser != "" && qbitPass != "" {
-			if err := login(client); err != nil {
-				log.Printf("Startup: Auth failed (%v). Retrying in 10s...", err)
-				if sleepOrExit(10 * time.Second) {
-					return
-				}
-				continue
-			}
-		}
-
-		// 2. Fetch Active Torrents
-		resp, err := client.Get(qbitHost + "/api/v2/torrents/info?filter=downloading")
-		if err != nil {
-			log.Printf("Startup: Connection failed (%v). Retrying in 10s...", err)
-			if sleepOrExit(10 * time.Second) {
-				return
-			}
-			continue
-		}
-
-		if resp.StatusCode != 200 {
-			log.Printf("Startup: API returned %d. Retrying in 10s...", resp.StatusCode)
-			_ = resp.Body.Close()
-			if sleepOrExit(10 * time.Second) {
-				return
-			}
-			continue
-		}
-
-		var torrents []Torrent
-		if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
-			log.Printf("Startup: JSON decode error (%v). Retrying in 10s...", err)
-			_ = resp.Body.Close()
-			if sleepOrExit(10 * time.Second) {
-				return
-			}
-			continue
-		}
-		_ = resp.Body.Close()
-
-		// 3. Sync
-		log.Printf("Startup: Found %d active downloads. Syncing...", len(torrents))
-		for _, t := range torrents {
-			mutex.Lock()
-			if !activeMonitors[t.Hash] {
-				activeMonitors[t.Hash] = true
-				mutex.Unlock()
-				log.Printf("Startup: Resuming monitor for %s (%s)", t.Name, t.Hash)
-				appWg.Add(1)
-				go trackTorrent(t.Hash)
-			} else {
-				mutex.Unlock()
-			}
-		}
-
-		log.Println("Startup: Sync complete.")
-		return
-	}
-}
-
+// handleTrackRequest is a cheap registration against the shared torrent
+// cache maintained by an instance's sync poller - it no longer spins up a
+// per-hash goroutine or makes any qBit API calls of its own.
 func handleTrackRequest(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	inst, ok := instanceLookup(r.URL.Query().Get("instance"))
+	if !ok {
+		http.Error(w, "Unknown or missing 'instance' query parameter", 400)
+		return
+	}
+
 	hash := r.URL.Query().Get("hash")
 	if hash == "" {
 		http.Error(w, "Missing 'hash' query parameter", 400)
 		return
 	}
 
-	mutex.Lock()
-	if activeMonitors[hash] {
-		mutex.Unlock()
+	switch registerMonitor(inst, hash) {
+	case registerAlreadyTracked:
 		_, _ = fmt.Fprintf(w, "Already tracking %s", hash)
-		return
+	case registerIgnored:
+		http.Error(w, "Torrent's category or tags are on the ignore list", http.StatusForbidden)
+	default:
+		w.WriteHeader(200)
+		_, _ = fmt.Fprintf(w, "Tracking started for %s", hash)
 	}
-	activeMonitors[hash] = true
-	mutex.Unlock()
-
-	appWg.Add(1)
-	go trackTorrent(hash)
-
-	w.WriteHeader(200)
-	_, _ = fmt.Fprintf(w, "Tracking started for %s", hash)
 }
 
-func trackTorrent(hash string) {
-	defer appWg.Done()
-	defer func() {
-		mutex.Lock()
-		delete(activeMonitors, hash)
-		mutex.Unlock()
-	}()
-
-	// Per-routine client to handle independent auth sessions cleanly
-	jar, _ := cookiejar.New(nil)
-	client := &http.Client{Jar: jar, Timeout: 5 * time.Second}
-
-	// Login only if credentials are provided
-	if qbitUser != "" && qbitPass != "" {
-		if err := login(client); err != nil {
-			log.Printf("[%s] Auth failed: %v", hash, err)
-			return
-		}
-	}
-
-	ticker := time.NewTicker(pollInt)
-	defer ticker.Stop()
-
-	// Fetch info immediately to get the name for logging
-	// We'll retry in the loop if this fails, but it's nice to log early if possible
-	startInfo, err := getTorrentInfo(client, hash)
-	if err == nil && startInfo != nil {
-		log.Printf("[%s] Monitor started for: %s", hash, startInfo.Name)
-	} else {
-		log.Printf("[%s] Monitor started (name pending...)", hash)
-	}
-
-	lastPct := -1
-
-	for {
-		select {
-		case <-appCtx.Done():
-			log.Printf("[%s] Shutting down monitor...", hash)
-			return
-		case <-ticker.C:
-			// Continue with logic below
-		}
-
-		t, err := getTorrentInfo(client, hash)
-		if err != nil {
-			log.Printf("[%s] Error: %v", hash, err)
-			continue
-		}
-		if t == nil {
-			log.Printf("[%s] Torrent removed. Stopping.", hash)
-			return
-		}
-
-		pct := int(t.Progress * 100)
-
-		// Update Notification if progress changed
-		if pct > lastPct {
-			lastPct = pct
-			sendUpdate(t, pct)
-		}
-
-		// Check Completion
-		// qBittorrent states: upload, uploading, upLO, pausedUP, completed, etc.
-		if pct >= 100 || strings.Contains(t.State, "up") || t.State == "completed" {
-			log.Printf("[%s] Torrent finished (%s). Stopping monitor.", hash, t.Name)
-			if notifyComplete {
-				sendComplete(t)
-			}
-			return
-		}
-	}
-}
-
-func sendUpdate(t *Torrent, pct int) {
-	speed := float64(t.DlSpeed) / 1024 / 1024
+func sendUpdate(inst *Instance, t *Torrent, pct int, rate float64) {
+	speed := humanize(rate) + "/s"
 	eta := formatDuration(t.Eta)
+	downloaded := fmt.Sprintf("Downloaded %s of %s", humanize(float64(t.Downloaded)), humanize(float64(t.Size)))
 
 	var msg string
 	if progressFormat == "percent" {
-		msg = fmt.Sprintf("Progress: %d%%\nSpeed: %.1f MB/s\nETA: %s", pct, speed, eta)
+		msg = fmt.Sprintf("Progress: %d%%\nSpeed: %s\nETA: %s\n%s", pct, speed, eta, downloaded)
 	} else {
 		bar := drawProgressBar(pct)
-		msg = fmt.Sprintf("%d%% %s\nSpeed: %.1f MB/s\nETA: %s", pct, bar, speed, eta)
+		msg = fmt.Sprintf("%d%% %s\nSpeed: %s\nETA: %s\n%s", pct, bar, speed, eta, downloaded)
 	}
 
-	sendNtfy(t.Name, msg, "arrow_down", "qbit-"+t.Hash, ntfyPrioProg)
+	sendNtfy(inst, routeTopic(inst, t), t.Name, msg, "arrow_down", "qbit-"+t.Hash, routePriority(t, ntfyPrioProg), actionsFor(inst, t.Hash), "progress")
 }
 
-func sendComplete(t *Torrent) {
-	sendNtfy("Download Complete", t.Name+" has finished downloading.", "white_check_mark", "qbit-"+t.Hash, ntfyPrioComp)
+func sendComplete(inst *Instance, t *Torrent) {
+	sendNtfy(inst, routeTopic(inst, t), "Download Complete", t.Name+" has finished downloading.", "white_check_mark", "qbit-"+t.Hash, routePriority(t, ntfyPrioComp), actionsFor(inst, t.Hash), "complete")
 }
 
-func sendNtfy(title, msg, tag, id, priority string) {
-	url := fmt.Sprintf("%s/%s", ntfyServer, ntfyTopic)
+func sendNtfy(inst *Instance, topic, title, msg, tag, id, priority, actions, kind string) {
+	url := fmt.Sprintf("%s/%s", ntfyServer, topic)
 	req, _ := http.NewRequest("POST", url, strings.NewReader(msg))
 	req.Header.Set("Title", title)
 	req.Header.Set("Tags", tag)
 	req.Header.Set("Priority", priority)
 	req.Header.Set("X-Sequence-ID", id)
+	if actions != "" {
+		req.Header.Set("Actions", actions)
+	}
 
 	if ntfyUser != "" && ntfyPass != "" {
 		req.SetBasicAuth(ntfyUser, ntfyPass)
@@ -352,39 +227,19 @@ func sendNtfy(title, msg, tag, id, priority string) {
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Failed to send ntfy notification: %v", err)
+		recordNtfyError(inst.ID)
 		return
 	}
 	defer func() { _ = resp.Body.Close() }()
+	recordNotificationSent(inst.ID, kind)
 }
 
-func getTorrentInfo(client *http.Client, hash string) (*Torrent, error) {
-	resp, err := client.Get(qbitHost + "/api/v2/torrents/info?hashes=" + hash)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("qBit API returned status: %d", resp.StatusCode)
-	}
-
-	var torrents []Torrent
-	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
-		return nil, err
-	}
-
-	if len(torrents) == 0 {
-		return nil, nil
-	}
-	return &torrents[0], nil
-}
-
-func login(client *http.Client) error {
+func login(client *http.Client, inst *Instance) error {
 	data := url.Values{}
-	data.Set("username", qbitUser)
-	data.Set("password", qbitPass)
+	data.Set("username", inst.User)
+	data.Set("password", inst.Pass)
 
-	resp, err := client.PostForm(qbitHost+"/api/v2/auth/login", data)
+	resp, err := client.PostForm(inst.Host+"/api/v2/auth/login", data)
 	if err != nil {
 		return err
 	}
@@ -433,6 +288,18 @@ func getEnv(k, fallback string) string {
 	return v
 }
 
+func getEnvInt(k string, fallback int) int {
+	v := os.Getenv(k)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
+
 func getEnvBool(k string, fallback bool) bool {
 	v := os.Getenv(k)
 	if v == "" {
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -49,93 +48,6 @@ func TestFormatDuration(t *testing.T) {
 	}
 }
 
-func TestGetTorrentInfo(t *testing.T) {
-	tests := []struct {
-		name          string
-		handler       func(w http.ResponseWriter, r *http.Request)
-		expectError   bool
-		expectTorrent bool
-		expectedHash  string
-	}{
-		{
-			name: "Success",
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(200)
-				_, _ = fmt.Fprintln(w, `[{"hash":"123","name":"Test Torrent","progress":0.5,"eta":60,"dlspeed":1024,"state":"downloading"}]`)
-			},
-			expectError:   false,
-			expectTorrent: true,
-			expectedHash:  "123",
-		},
-		{
-			name: "Torrent Not Found (Empty Array)",
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(200)
-				_, _ = fmt.Fprintln(w, `[]`)
-			},
-			expectError:   false,
-			expectTorrent: false,
-		},
-		{
-			name: "API Error (500)",
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(500)
-				_, _ = fmt.Fprintln(w, `Internal Server Error`)
-			},
-			expectError:   true,
-			expectTorrent: false,
-		},
-		{
-			name: "Malformed JSON",
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(200)
-				_, _ = fmt.Fprintln(w, `[{"hash":... invalid json ...`)
-			},
-			expectError:   true,
-			expectTorrent: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				if strings.Contains(r.URL.Path, "/api/v2/torrents/info") {
-					tt.handler(w, r)
-					return
-				}
-				w.WriteHeader(404)
-			}))
-			defer ts.Close()
-
-			// Override global host
-			oldHost := qbitHost
-			qbitHost = ts.URL
-			defer func() { qbitHost = oldHost }()
-
-			client := ts.Client()
-			torrent, err := getTorrentInfo(client, "123")
-
-			if tt.expectError && err == nil {
-				t.Error("Expected error, got nil")
-			}
-			if !tt.expectError && err != nil {
-				t.Errorf("Unexpected error: %v", err)
-			}
-
-			if tt.expectTorrent && torrent == nil {
-				t.Error("Expected torrent, got nil")
-			}
-			if !tt.expectTorrent && torrent != nil {
-				t.Errorf("Expected nil torrent, got %v", torrent)
-			}
-
-			if tt.expectTorrent && torrent != nil && torrent.Hash != tt.expectedHash {
-				t.Errorf("Expected hash %s, got %s", tt.expectedHash, torrent.Hash)
-			}
-		})
-	}
-}
-
 func TestSendNtfy(t *testing.T) {
 	// Mock Ntfy Server
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -203,12 +115,13 @@ func TestSendNtfy(t *testing.T) {
 	ntfyServer = ts.URL
 	ntfyTopic = "test_topic"
 
+	inst := newInstance("test", "http://qbit.local", "", "", ntfyTopic, nil)
+
 	// 1. Test standard notification (no auth)
-	sendNtfy("Test Title", "Test Message", "tag", "id", "3")
+	sendNtfy(inst, "test_topic", "Test Title", "Test Message", "tag", "id", "3", "", "progress")
 
 	// 2. Test authenticated notification
-	ntfyTopic = "auth_topic"
 	ntfyUser = "testuser"
 	ntfyPass = "testpass"
-	sendNtfy("Auth Title", "Auth Message", "tag", "id", "3")
+	sendNtfy(inst, "auth_topic", "Auth Title", "Auth Message", "tag", "id", "3", "", "progress")
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// --- Metrics state ---
+// These are populated from the same per-instance torrentCache /
+// activeMonitors that drive notifications, so labels stay consistent
+// between ntfy messages and what /metrics reports. Counters are keyed by
+// instance ID since each instance runs its own poller and ntfy topic.
+var (
+	metricsMutex       sync.Mutex
+	notificationsSent  = make(map[string]map[string]int) // instanceID -> kind -> count
+	ntfyErrorsTotal    = make(map[string]int)            // instanceID -> count
+	qbitAPIErrorsTotal = make(map[string]int)            // instanceID -> count
+)
+
+func recordNotificationSent(instanceID, kind string) {
+	metricsMutex.Lock()
+	if notificationsSent[instanceID] == nil {
+		notificationsSent[instanceID] = make(map[string]int)
+	}
+	notificationsSent[instanceID][kind]++
+	metricsMutex.Unlock()
+}
+
+func recordNtfyError(instanceID string) {
+	metricsMutex.Lock()
+	ntfyErrorsTotal[instanceID]++
+	metricsMutex.Unlock()
+}
+
+func recordQbitAPIError(instanceID string) {
+	metricsMutex.Lock()
+	qbitAPIErrorsTotal[instanceID]++
+	metricsMutex.Unlock()
+}
+
+// handleMetrics serves a Prometheus text-exposition snapshot of current
+// torrent state and notification counters across all configured
+// instances. No client library is used - the format is simple enough to
+// write by hand and it keeps this sidecar dependency-free.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	var b strings.Builder
+	writeMetrics(&b)
+	_, _ = w.Write([]byte(b.String()))
+}
+
+func writeMetrics(b *strings.Builder) {
+	b.WriteString("# HELP qbit_torrent_progress Download progress as a fraction between 0 and 1.\n")
+	b.WriteString("# TYPE qbit_torrent_progress gauge\n")
+	for _, inst := range instances {
+		for _, t := range instanceTorrents(inst) {
+			fmt.Fprintf(b, "qbit_torrent_progress{instance=%q,hash=%q,name=%q,category=%q} %v\n", inst.ID, t.Hash, t.Name, t.Category, t.Progress)
+		}
+	}
+
+	b.WriteString("# HELP qbit_torrent_dlspeed_bytes Download speed in bytes per second, smoothed over the sample window once it holds two samples, otherwise as reported by qBittorrent.\n")
+	b.WriteString("# TYPE qbit_torrent_dlspeed_bytes gauge\n")
+	for _, inst := range instances {
+		for _, t := range instanceTorrents(inst) {
+			fmt.Fprintf(b, "qbit_torrent_dlspeed_bytes{instance=%q,hash=%q,name=%q,category=%q} %v\n", inst.ID, t.Hash, t.Name, t.Category, smoothedRateOrFallback(inst.ID, t.Hash, float64(t.DlSpeed)))
+		}
+	}
+
+	b.WriteString("# HELP qbit_torrent_eta_seconds Estimated time to completion, in seconds.\n")
+	b.WriteString("# TYPE qbit_torrent_eta_seconds gauge\n")
+	for _, inst := range instances {
+		for _, t := range instanceTorrents(inst) {
+			fmt.Fprintf(b, "qbit_torrent_eta_seconds{instance=%q,hash=%q,name=%q,category=%q} %d\n", inst.ID, t.Hash, t.Name, t.Category, t.Eta)
+		}
+	}
+
+	b.WriteString("# HELP qbit_torrent_state Set to 1 for the torrent's current state.\n")
+	b.WriteString("# TYPE qbit_torrent_state gauge\n")
+	for _, inst := range instances {
+		for _, t := range instanceTorrents(inst) {
+			fmt.Fprintf(b, "qbit_torrent_state{instance=%q,hash=%q,name=%q,state=%q} 1\n", inst.ID, t.Hash, t.Name, t.State)
+		}
+	}
+
+	metricsMutex.Lock()
+	defer metricsMutex.Unlock()
+
+	b.WriteString("# HELP qbit_notifications_sent_total Notifications sent to ntfy, by instance and kind.\n")
+	b.WriteString("# TYPE qbit_notifications_sent_total counter\n")
+	for _, inst := range instances {
+		counts := notificationsSent[inst.ID]
+		fmt.Fprintf(b, "qbit_notifications_sent_total{instance=%q,kind=\"progress\"} %d\n", inst.ID, counts["progress"])
+		fmt.Fprintf(b, "qbit_notifications_sent_total{instance=%q,kind=\"complete\"} %d\n", inst.ID, counts["complete"])
+	}
+
+	b.WriteString("# HELP qbit_ntfy_errors_total Errors encountered while sending ntfy notifications.\n")
+	b.WriteString("# TYPE qbit_ntfy_errors_total counter\n")
+	for _, inst := range instances {
+		fmt.Fprintf(b, "qbit_ntfy_errors_total{instance=%q} %d\n", inst.ID, ntfyErrorsTotal[inst.ID])
+	}
+
+	b.WriteString("# HELP qbit_qbit_api_errors_total Errors encountered while calling the qBittorrent Web API.\n")
+	b.WriteString("# TYPE qbit_qbit_api_errors_total counter\n")
+	for _, inst := range instances {
+		fmt.Fprintf(b, "qbit_qbit_api_errors_total{instance=%q} %d\n", inst.ID, qbitAPIErrorsTotal[inst.ID])
+	}
+
+	b.WriteString("# HELP qbit_active_monitors Number of torrents currently being monitored for notifications.\n")
+	b.WriteString("# TYPE qbit_active_monitors gauge\n")
+	for _, inst := range instances {
+		inst.mu.Lock()
+		count := len(inst.activeMonitors)
+		inst.mu.Unlock()
+		fmt.Fprintf(b, "qbit_active_monitors{instance=%q} %d\n", inst.ID, count)
+	}
+}
+
+// instanceTorrents returns a snapshot copy of an instance's torrent cache
+// so callers don't hold inst.mu while formatting metrics lines.
+func instanceTorrents(inst *Instance) []*Torrent {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	torrents := make([]*Torrent, 0, len(inst.torrentCache))
+	for _, t := range inst.torrentCache {
+		torrents = append(torrents, t)
+	}
+	return torrents
+}
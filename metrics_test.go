@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	oldInstances, oldByID := instances, instanceByID
+	oldSent, oldNtfyErr, oldQbitErr := notificationsSent, ntfyErrorsTotal, qbitAPIErrorsTotal
+	t.Cleanup(func() {
+		instances, instanceByID = oldInstances, oldByID
+		notificationsSent, ntfyErrorsTotal, qbitAPIErrorsTotal = oldSent, oldNtfyErr, oldQbitErr
+	})
+
+	inst := newInstance("home", "http://qbit.local", "", "", "topic", nil)
+	inst.torrentCache["abc"] = &Torrent{Hash: "abc", Name: "Movie", Progress: 0.5, DlSpeed: 1024, Eta: 60, State: "downloading", Category: "movies"}
+	inst.activeMonitors["abc"] = &monitorState{lastPct: 50}
+
+	instances = []*Instance{inst}
+	instanceByID = map[string]*Instance{"home": inst}
+
+	notificationsSent = map[string]map[string]int{"home": {"progress": 3, "complete": 1}}
+	ntfyErrorsTotal = map[string]int{"home": 2}
+	qbitAPIErrorsTotal = map[string]int{"home": 1}
+
+	var b strings.Builder
+	writeMetrics(&b)
+	out := b.String()
+
+	for _, want := range []string{
+		`qbit_torrent_progress{instance="home",hash="abc",name="Movie",category="movies"} 0.5`,
+		`qbit_torrent_dlspeed_bytes{instance="home",hash="abc",name="Movie",category="movies"} 1024`,
+		`qbit_torrent_eta_seconds{instance="home",hash="abc",name="Movie",category="movies"} 60`,
+		`qbit_torrent_state{instance="home",hash="abc",name="Movie",state="downloading"} 1`,
+		`qbit_notifications_sent_total{instance="home",kind="progress"} 3`,
+		`qbit_notifications_sent_total{instance="home",kind="complete"} 1`,
+		`qbit_ntfy_errors_total{instance="home"} 2`,
+		`qbit_qbit_api_errors_total{instance="home"} 1`,
+		`qbit_active_monitors{instance="home"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected metrics output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"strings"
+)
+
+// --- Routing config ---
+// Populated from dynamically-named env vars, e.g. NTFY_TOPIC_CATEGORY_movies
+// or NTFY_PRIORITY_CATEGORY_linux-iso, so new categories/tags don't need
+// code changes - only new env vars.
+var (
+	ignoreCategories []string
+	ignoreTags       []string
+
+	topicByCategory    map[string]string
+	topicByTag         map[string]string
+	priorityByCategory map[string]string
+)
+
+func parseRouting() {
+	ignoreCategories = splitList(getEnv("IGNORE_CATEGORIES", ""))
+	ignoreTags = splitList(getEnv("IGNORE_TAGS", ""))
+
+	topicByCategory = make(map[string]string)
+	topicByTag = make(map[string]string)
+	priorityByCategory = make(map[string]string)
+
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key, val := parts[0], parts[1]
+
+		switch {
+		case strings.HasPrefix(key, "NTFY_TOPIC_CATEGORY_"):
+			topicByCategory[strings.ToLower(strings.TrimPrefix(key, "NTFY_TOPIC_CATEGORY_"))] = val
+		case strings.HasPrefix(key, "NTFY_TOPIC_TAG_"):
+			topicByTag[strings.ToLower(strings.TrimPrefix(key, "NTFY_TOPIC_TAG_"))] = val
+		case strings.HasPrefix(key, "NTFY_PRIORITY_CATEGORY_"):
+			priorityByCategory[strings.ToLower(strings.TrimPrefix(key, "NTFY_PRIORITY_CATEGORY_"))] = val
+		}
+	}
+}
+
+// splitList parses a comma-separated env var into a trimmed slice, or nil
+// if it's empty.
+func splitList(raw string) []string {
+	if raw == "" {
+		return nil
+	}
+	parts := strings.Split(raw, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func containsFold(list []string, val string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// isIgnored reports whether a torrent's category or any of its tags are
+// on the IGNORE_CATEGORIES / IGNORE_TAGS blacklist.
+func isIgnored(t *Torrent) bool {
+	if containsFold(ignoreCategories, t.Category) {
+		return true
+	}
+	for _, tag := range splitList(t.Tags) {
+		if containsFold(ignoreTags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeTopic picks the ntfy topic for a torrent: a tag match wins first,
+// then category, then the instance's own default topic. Lookups are
+// case-insensitive, matching the IGNORE_CATEGORIES/IGNORE_TAGS behavior in
+// isIgnored above.
+func routeTopic(inst *Instance, t *Torrent) string {
+	for _, tag := range splitList(t.Tags) {
+		if topic, ok := topicByTag[strings.ToLower(tag)]; ok {
+			return topic
+		}
+	}
+	if topic, ok := topicByCategory[strings.ToLower(t.Category)]; ok {
+		return topic
+	}
+	return inst.Topic
+}
+
+// routePriority applies a per-category ntfy priority override, e.g. so
+// linux-iso finishes stay silent while media finishes ring. Lookup is
+// case-insensitive, matching routeTopic/isIgnored.
+func routePriority(t *Torrent, fallback string) string {
+	if p, ok := priorityByCategory[strings.ToLower(t.Category)]; ok {
+		return p
+	}
+	return fallback
+}
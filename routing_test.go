@@ -0,0 +1,76 @@
+package main
+
+import "testing"
+
+func TestIsIgnored(t *testing.T) {
+	oldCategories, oldTags := ignoreCategories, ignoreTags
+	t.Cleanup(func() { ignoreCategories, ignoreTags = oldCategories, oldTags })
+
+	ignoreCategories = []string{"linux-iso"}
+	ignoreTags = []string{"archive"}
+
+	tests := []struct {
+		name     string
+		torrent  *Torrent
+		expected bool
+	}{
+		{"Ignored category", &Torrent{Category: "linux-iso"}, true},
+		{"Ignored category case-insensitive", &Torrent{Category: "Linux-ISO"}, true},
+		{"Ignored tag among several", &Torrent{Tags: "keep, archive"}, true},
+		{"Not ignored", &Torrent{Category: "movies", Tags: "important"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isIgnored(tt.torrent); got != tt.expected {
+				t.Errorf("isIgnored(%+v): expected %v, got %v", tt.torrent, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRouteTopic(t *testing.T) {
+	oldCategory, oldTag := topicByCategory, topicByTag
+	t.Cleanup(func() { topicByCategory, topicByTag = oldCategory, oldTag })
+
+	topicByCategory = map[string]string{"movies": "media-alerts"}
+	topicByTag = map[string]string{"important": "priority-high"}
+
+	inst := newInstance("home", "http://qbit.local", "", "", "default-topic", nil)
+
+	tests := []struct {
+		name     string
+		torrent  *Torrent
+		expected string
+	}{
+		{"Tag wins over category", &Torrent{Category: "movies", Tags: "important"}, "priority-high"},
+		{"Category match", &Torrent{Category: "movies"}, "media-alerts"},
+		{"Category match case-insensitive", &Torrent{Category: "Movies"}, "media-alerts"},
+		{"Falls back to instance default", &Torrent{Category: "linux-iso"}, "default-topic"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := routeTopic(inst, tt.torrent); got != tt.expected {
+				t.Errorf("routeTopic: expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRoutePriority(t *testing.T) {
+	oldPriority := priorityByCategory
+	t.Cleanup(func() { priorityByCategory = oldPriority })
+
+	priorityByCategory = map[string]string{"linux-iso": "1"}
+
+	if got := routePriority(&Torrent{Category: "linux-iso"}, "3"); got != "1" {
+		t.Errorf("expected override priority 1, got %s", got)
+	}
+	if got := routePriority(&Torrent{Category: "Linux-ISO"}, "3"); got != "1" {
+		t.Errorf("expected case-insensitive override priority 1, got %s", got)
+	}
+	if got := routePriority(&Torrent{Category: "movies"}, "3"); got != "3" {
+		t.Errorf("expected fallback priority 3, got %s", got)
+	}
+}
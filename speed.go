@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// speedSample is one (timestamp, bytes downloaded so far) observation used
+// to smooth out the instantaneous dlspeed qBit reports each tick.
+type speedSample struct {
+	at         time.Time
+	downloaded int64
+}
+
+var (
+	speedMutex   sync.Mutex
+	speedSamples = make(map[string][]speedSample)
+)
+
+// speedKey namespaces the sample ring by instance so two instances
+// tracking the same hash (unlikely, but possible with a misconfigured
+// seedbox pair) don't share a window.
+func speedKey(instanceID, hash string) string {
+	return instanceID + "|" + hash
+}
+
+// recordSample appends a new observation for hash on instanceID, trimming
+// the ring down to speedWindow entries.
+func recordSample(instanceID, hash string, downloaded int64) {
+	speedMutex.Lock()
+	defer speedMutex.Unlock()
+
+	key := speedKey(instanceID, hash)
+	samples := append(speedSamples[key], speedSample{at: time.Now(), downloaded: downloaded})
+	if len(samples) > speedWindow {
+		samples = samples[len(samples)-speedWindow:]
+	}
+	speedSamples[key] = samples
+}
+
+// smoothedRate returns bytes/sec averaged over whatever's in the window,
+// rather than the single noisy instantaneous tick qBit reports.
+func smoothedRate(instanceID, hash string) float64 {
+	speedMutex.Lock()
+	defer speedMutex.Unlock()
+
+	samples := speedSamples[speedKey(instanceID, hash)]
+	if len(samples) < 2 {
+		return 0
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	elapsed := last.at.Sub(first.at).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(last.downloaded-first.downloaded) / elapsed
+}
+
+// smoothedRateOrFallback is smoothedRate, but returns fallback instead of 0
+// when the window doesn't yet have two samples to average over - e.g. a
+// torrent that's cached but was never /track-ed, so recordSample never ran.
+func smoothedRateOrFallback(instanceID, hash string, fallback float64) float64 {
+	speedMutex.Lock()
+	samples := speedSamples[speedKey(instanceID, hash)]
+	speedMutex.Unlock()
+
+	if len(samples) < 2 {
+		return fallback
+	}
+	return smoothedRate(instanceID, hash)
+}
+
+// clearSpeedSamples drops a hash's sample ring once it stops being
+// tracked, so the map doesn't grow unbounded over the sidecar's lifetime.
+func clearSpeedSamples(instanceID, hash string) {
+	speedMutex.Lock()
+	delete(speedSamples, speedKey(instanceID, hash))
+	speedMutex.Unlock()
+}
+
+// humanize formats a byte count using binary (KiB/MiB/...) units,
+// switching magnitude automatically the way `ls -h` / `du -h` do.
+func humanize(b float64) string {
+	const unit = 1024.0
+	if b < unit {
+		return fmt.Sprintf("%.0f B", b)
+	}
+	div, exp := unit, 0
+	for n := b / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", b/div, "KMGT"[exp])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		bytes    float64
+		expected string
+	}{
+		{0, "0 B"},
+		{512, "512 B"},
+		{1536, "1.5 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{1024 * 1024 * 1024 * 2.5, "2.5 GiB"},
+		{1024 * 1024 * 1024 * 1024, "1.0 TiB"},
+		{1024 * 1024 * 1024 * 1024 * 1024, "1024.0 TiB"}, // caps at TiB instead of indexing past "KMGT"
+	}
+
+	for _, tt := range tests {
+		if got := humanize(tt.bytes); got != tt.expected {
+			t.Errorf("humanize(%v): expected %s, got %s", tt.bytes, tt.expected, got)
+		}
+	}
+}
+
+func TestSmoothedRate(t *testing.T) {
+	oldSamples, oldWindow := speedSamples, speedWindow
+	t.Cleanup(func() {
+		speedSamples = oldSamples
+		speedWindow = oldWindow
+	})
+
+	speedSamples = make(map[string][]speedSample)
+	speedWindow = 3
+
+	if rate := smoothedRate("default", "abc"); rate != 0 {
+		t.Fatalf("expected 0 with no samples, got %v", rate)
+	}
+
+	now := time.Now()
+	speedSamples[speedKey("default", "abc")] = []speedSample{
+		{at: now, downloaded: 1000},
+		{at: now.Add(2 * time.Second), downloaded: 3000},
+	}
+
+	if rate := smoothedRate("default", "abc"); rate != 1000 {
+		t.Errorf("expected rate 1000 B/s, got %v", rate)
+	}
+
+	// Ring trims down to speedWindow entries.
+	recordSample("default", "xyz", 100)
+	recordSample("default", "xyz", 200)
+	recordSample("default", "xyz", 300)
+	recordSample("default", "xyz", 400)
+	if got := len(speedSamples[speedKey("default", "xyz")]); got != speedWindow {
+		t.Errorf("expected ring trimmed to %d samples, got %d", speedWindow, got)
+	}
+}
+
+func TestSmoothedRateOrFallback(t *testing.T) {
+	oldSamples := speedSamples
+	t.Cleanup(func() { speedSamples = oldSamples })
+
+	speedSamples = make(map[string][]speedSample)
+
+	if rate := smoothedRateOrFallback("default", "abc", 500); rate != 500 {
+		t.Errorf("expected fallback 500 with <2 samples, got %v", rate)
+	}
+
+	now := time.Now()
+	speedSamples[speedKey("default", "abc")] = []speedSample{
+		{at: now, downloaded: 1000},
+		{at: now.Add(2 * time.Second), downloaded: 3000},
+	}
+
+	if rate := smoothedRateOrFallback("default", "abc", 500); rate != 1000 {
+		t.Errorf("expected smoothed rate 1000 once 2 samples exist, got %v", rate)
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// monitorState tracks what we've already notified about for a hash we're
+// actively watching. Presence as a key in an instance's activeMonitors is
+// what makes a hash "subscribed" - the sync poller fans out change events
+// to whatever is registered here.
+type monitorState struct {
+	lastPct int
+}
+
+// MainData mirrors the shape of qBit's /api/v2/sync/maindata response.
+// Torrents is kept as raw JSON per-hash so partial (delta) updates can be
+// merged onto whatever we already know about that torrent instead of
+// clobbering fields the delta didn't include.
+type MainData struct {
+	Rid             int                        `json:"rid"`
+	FullUpdate      bool                       `json:"full_update"`
+	Torrents        map[string]json.RawMessage `json:"torrents"`
+	TorrentsRemoved []string                   `json:"torrents_removed"`
+}
+
+// startupScan waits for qBittorrent to come up, performs the initial
+// full-update sync (rid=0), resumes monitors for whatever is still
+// downloading, then hands off to the long-running syncPoller.
+func startupScan(inst *Instance) {
+	defer appWg.Done()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, Timeout: 10 * time.Second}
+
+	sleepOrExit := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return false
+		case <-appCtx.Done():
+			return true
+		}
+	}
+
+	for {
+		select {
+		case <-appCtx.Done():
+			return
+		default:
+		}
+
+		log.Printf("[%s] Startup: Attempting to connect to qBittorrent...", inst.ID)
+
+		if inst.User != "" && inst.Pass != "" {
+			if err := login(client, inst); err != nil {
+				log.Printf("[%s] Startup: Auth failed (%v). Retrying in 10s...", inst.ID, err)
+				if sleepOrExit(10 * time.Second) {
+					return
+				}
+				continue
+			}
+		}
+
+		data, err := fetchMainData(inst, client, 0)
+		if err != nil {
+			log.Printf("[%s] Startup: Connection failed (%v). Retrying in 10s...", inst.ID, err)
+			if sleepOrExit(10 * time.Second) {
+				return
+			}
+			continue
+		}
+
+		changed := mergeMainData(inst, data)
+		log.Printf("[%s] Startup: Found %d torrents. Syncing...", inst.ID, len(changed))
+
+		inst.mu.Lock()
+		for _, hash := range changed {
+			t := inst.torrentCache[hash]
+			if t == nil || !isActiveDownload(t) || !inst.trackedCategory(t.Category) || isIgnored(t) {
+				continue
+			}
+			if _, ok := inst.activeMonitors[hash]; !ok {
+				inst.activeMonitors[hash] = &monitorState{lastPct: -1}
+				log.Printf("[%s] Startup: Resuming monitor for %s (%s)", inst.ID, t.Name, hash)
+			}
+		}
+		inst.mu.Unlock()
+
+		log.Printf("[%s] Startup: Sync complete.", inst.ID)
+		inst.setClient(client)
+		syncPoller(inst, client, data.Rid)
+		return
+	}
+}
+
+// isActiveDownload reports whether a torrent's state looks like it's still
+// downloading, i.e. roughly what the old "filter=downloading" query param
+// used to select server-side.
+func isActiveDownload(t *Torrent) bool {
+	if t.Progress >= 1 {
+		return false
+	}
+	if strings.Contains(t.State, "up") || strings.Contains(t.State, "paused") {
+		return false
+	}
+	return t.State != "completed" && t.State != "error" && t.State != "missingFiles"
+}
+
+// syncPoller is the single background loop per instance that replaces the
+// old one-goroutine-per-torrent polling: each tick it asks qBit for
+// whatever changed since rid, merges the delta into the instance's
+// torrentCache, and fans the change out to any hash with a registered
+// monitor.
+func syncPoller(inst *Instance, client *http.Client, rid int) {
+	ticker := time.NewTicker(pollInt)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-appCtx.Done():
+			log.Printf("[%s] Sync: Shutting down poller...", inst.ID)
+			return
+		case <-ticker.C:
+		}
+
+		data, err := fetchMainData(inst, client, rid)
+		if err != nil {
+			log.Printf("[%s] Sync: Error fetching maindata: %v", inst.ID, err)
+			recordQbitAPIError(inst.ID)
+			continue
+		}
+		rid = data.Rid
+
+		for _, hash := range mergeMainData(inst, data) {
+			processTorrentChange(inst, hash)
+		}
+	}
+}
+
+// fetchMainData calls qBit's incremental sync endpoint for whatever has
+// changed since rid (rid=0 requests a full_update).
+func fetchMainData(inst *Instance, client *http.Client, rid int) (*MainData, error) {
+	resp, err := client.Get(inst.Host + "/api/v2/sync/maindata?rid=" + strconv.Itoa(rid))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("qBit API returned status: %d", resp.StatusCode)
+	}
+
+	var data MainData
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// mergeMainData folds a MainData response into an instance's torrentCache
+// and returns the hashes that changed (new or updated) this round.
+// Removals are applied directly here since they don't need a
+// notification pass.
+func mergeMainData(inst *Instance, data *MainData) []string {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+
+	if data.FullUpdate {
+		inst.torrentCache = make(map[string]*Torrent, len(data.Torrents))
+	}
+
+	changed := make([]string, 0, len(data.Torrents))
+	for hash, raw := range data.Torrents {
+		var t Torrent
+		if existing, ok := inst.torrentCache[hash]; ok {
+			t = *existing // seed from previous state so fields missing from the delta survive
+		}
+		if err := json.Unmarshal(raw, &t); err != nil {
+			log.Printf("[%s] [sync] Failed to decode delta for %s: %v", inst.ID, hash, err)
+			continue
+		}
+		t.Hash = hash
+		inst.torrentCache[hash] = &t
+		changed = append(changed, hash)
+	}
+
+	for _, hash := range data.TorrentsRemoved {
+		delete(inst.torrentCache, hash)
+		clearSpeedSamples(inst.ID, hash)
+		if _, tracked := inst.activeMonitors[hash]; tracked {
+			delete(inst.activeMonitors, hash)
+			log.Printf("[%s] [%s] Torrent removed. Stopping monitor.", inst.ID, hash)
+		}
+	}
+
+	return changed
+}
+
+// registerResult is the outcome of registerMonitor.
+type registerResult int
+
+const (
+	registerStarted registerResult = iota
+	registerAlreadyTracked
+	registerIgnored
+)
+
+// registerMonitor subscribes hash to change notifications on inst, unless
+// its category/tags are on the ignore list.
+func registerMonitor(inst *Instance, hash string) registerResult {
+	inst.mu.Lock()
+	if _, ok := inst.activeMonitors[hash]; ok {
+		inst.mu.Unlock()
+		return registerAlreadyTracked
+	}
+	if t, exists := inst.torrentCache[hash]; exists && isIgnored(t) {
+		inst.mu.Unlock()
+		return registerIgnored
+	}
+	inst.activeMonitors[hash] = &monitorState{lastPct: -1}
+	inst.mu.Unlock()
+
+	// Torrent may already be in the shared cache (it was discovered by an
+	// earlier sync tick); send an immediate update if so instead of
+	// waiting for it to change again.
+	processTorrentChange(inst, hash)
+	return registerStarted
+}
+
+// processTorrentChange evaluates whatever state an instance's
+// torrentCache currently holds for hash against what we last notified
+// about, sending progress and completion notifications as needed.
+func processTorrentChange(inst *Instance, hash string) {
+	inst.mu.Lock()
+	state, tracked := inst.activeMonitors[hash]
+	t, exists := inst.torrentCache[hash]
+	if !tracked || !exists {
+		inst.mu.Unlock()
+		return
+	}
+
+	// The category/tags may only have become known after registration
+	// (e.g. the hash was tracked before its first sync tick); re-check
+	// here so an ignored torrent doesn't keep notifying.
+	if isIgnored(t) {
+		delete(inst.activeMonitors, hash)
+		inst.mu.Unlock()
+		log.Printf("[%s] [%s] Category/tag ignored (%s). Stopping monitor.", inst.ID, hash, t.Category)
+		clearSpeedSamples(inst.ID, hash)
+		return
+	}
+
+	// lastPct is read, compared and written entirely under inst.mu so a
+	// monitor registered on the /track goroutine and processed by
+	// syncPoller at the same instant can't race on the same *monitorState.
+	pct := int(t.Progress * 100)
+	shouldUpdate := pct > state.lastPct
+	if shouldUpdate {
+		state.lastPct = pct
+	}
+
+	// qBittorrent states: uploading, stalledUP, pausedUP, completed, etc.
+	finished := pct >= 100 || strings.Contains(strings.ToLower(t.State), "up") || t.State == "completed"
+	if finished {
+		delete(inst.activeMonitors, hash)
+	}
+	inst.mu.Unlock()
+
+	recordSample(inst.ID, hash, t.Downloaded)
+	rate := smoothedRate(inst.ID, hash)
+
+	if shouldUpdate {
+		sendUpdate(inst, t, pct, rate)
+	}
+
+	if finished {
+		log.Printf("[%s] [%s] Torrent finished (%s). Stopping monitor.", inst.ID, hash, t.Name)
+		if notifyComplete {
+			sendComplete(inst, t)
+		}
+		clearSpeedSamples(inst.ID, hash)
+	}
+}
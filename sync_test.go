@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchMainData(t *testing.T) {
+	tests := []struct {
+		name        string
+		handler     func(w http.ResponseWriter, r *http.Request)
+		expectError bool
+		expectRid   int
+	}{
+		{
+			name: "Full update",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				_, _ = fmt.Fprintln(w, `{"rid":1,"full_update":true,"torrents":{"123":{"hash":"123","name":"Test","progress":0.5,"state":"downloading"}}}`)
+			},
+			expectError: false,
+			expectRid:   1,
+		},
+		{
+			name: "API Error (500)",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(500)
+				_, _ = fmt.Fprintln(w, `Internal Server Error`)
+			},
+			expectError: true,
+		},
+		{
+			name: "Malformed JSON",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(200)
+				_, _ = fmt.Fprintln(w, `{"rid":... invalid json ...`)
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.Contains(r.URL.Path, "/api/v2/sync/maindata") {
+					tt.handler(w, r)
+					return
+				}
+				w.WriteHeader(404)
+			}))
+			defer ts.Close()
+
+			inst := newInstance("test", ts.URL, "", "", "topic", nil)
+
+			data, err := fetchMainData(inst, ts.Client(), 0)
+
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !tt.expectError && data.Rid != tt.expectRid {
+				t.Errorf("Expected rid %d, got %d", tt.expectRid, data.Rid)
+			}
+		})
+	}
+}
+
+func TestMergeMainData(t *testing.T) {
+	inst := newInstance("test", "http://qbit.local", "", "", "topic", nil)
+
+	// Full update seeds the cache.
+	full := &MainData{
+		Rid:        1,
+		FullUpdate: true,
+		Torrents: map[string]json.RawMessage{
+			"abc": json.RawMessage(`{"hash":"abc","name":"Movie","progress":0.1,"dlspeed":100,"state":"downloading"}`),
+		},
+	}
+	changed := mergeMainData(inst, full)
+	if len(changed) != 1 || changed[0] != "abc" {
+		t.Fatalf("expected [abc], got %v", changed)
+	}
+	if inst.torrentCache["abc"].Name != "Movie" {
+		t.Fatalf("expected name Movie, got %s", inst.torrentCache["abc"].Name)
+	}
+
+	// Delta only carries progress+dlspeed - name must survive the merge.
+	delta := &MainData{
+		Rid: 2,
+		Torrents: map[string]json.RawMessage{
+			"abc": json.RawMessage(`{"progress":0.4,"dlspeed":200}`),
+		},
+	}
+	mergeMainData(inst, delta)
+	got := inst.torrentCache["abc"]
+	if got.Name != "Movie" {
+		t.Errorf("expected name to survive partial merge, got %q", got.Name)
+	}
+	if got.Progress != 0.4 || got.DlSpeed != 200 {
+		t.Errorf("expected delta fields to apply, got progress=%v dlspeed=%v", got.Progress, got.DlSpeed)
+	}
+
+	// Removal drops the cache entry and any registered monitor.
+	inst.activeMonitors["abc"] = &monitorState{lastPct: -1}
+	removal := &MainData{
+		Rid:             3,
+		TorrentsRemoved: []string{"abc"},
+	}
+	mergeMainData(inst, removal)
+	if _, ok := inst.torrentCache["abc"]; ok {
+		t.Error("expected abc to be removed from torrentCache")
+	}
+	if _, ok := inst.activeMonitors["abc"]; ok {
+		t.Error("expected abc's monitor to be removed")
+	}
+}